@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// Hasher is the interface implemented by every hashing algorithm backend
+// registered with the service. New must return a fresh hash.Hash that's
+// safe for a single caller to write to and sum; handing out a streaming
+// hash.Hash (rather than a one-shot Sum([]byte)) is what lets jobToReply
+// stream large payloads instead of buffering them.
+type Hasher interface {
+	// Name is the algorithm identifier as it appears in Job.Alg, e.g. "SHA256".
+	Name() string
+	// New returns a new, zeroed streaming hash.Hash for this algorithm.
+	New() hash.Hash
+}
+
+// simpleHasher adapts a hash.Hash constructor func to the Hasher interface.
+// Both the default and simd backends are just collections of these.
+type simpleHasher struct {
+	name string
+	new  func() hash.Hash
+}
+
+func (s simpleHasher) Name() string   { return s.name }
+func (s simpleHasher) New() hash.Hash { return s.new() }
+
+var (
+	hasherRegistryMu sync.RWMutex
+	hasherRegistry   = map[string]Hasher{}
+)
+
+// RegisterHasher makes a Hasher available under name, overwriting any
+// previous registration. It's meant to be called from init() by backend
+// files (see hasher_default.go, hasher_simd.go) and by third-party code
+// that wants to add algorithms without patching HashValue.
+func RegisterHasher(name string, h Hasher) {
+	hasherRegistryMu.Lock()
+	defer hasherRegistryMu.Unlock()
+	hasherRegistry[name] = h
+}
+
+// GetHasher looks up a previously registered Hasher by name.
+func GetHasher(name string) (Hasher, bool) {
+	hasherRegistryMu.RLock()
+	defer hasherRegistryMu.RUnlock()
+	h, ok := hasherRegistry[name]
+	return h, ok
+}
+
+// HashValue hashes the input string using the algorithm registered under
+// that name, returning "" if the algorithm isn't known. It's a small
+// convenience wrapper around the streaming Hasher interface for short,
+// in-memory inputs; jobToReply uses the streaming form directly.
+func HashValue(input string, algorithm string) string {
+	hasher, ok := GetHasher(algorithm)
+	if !ok {
+		return ""
+	}
+	h := hasher.New()
+	h.Write([]byte(input))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,48 @@
+//go:build !simd
+
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// blake2bHasher exposes BLAKE2b's native keyed-hashing mode (via
+// KeyedHasher) instead of going through a generic crypto/hmac wrapper.
+type blake2bHasher struct{}
+
+func (blake2bHasher) Name() string { return "BLAKE2b" }
+func (blake2bHasher) New() hash.Hash {
+	h, _ := blake2b.New512(nil)
+	return h
+}
+func (blake2bHasher) NewKeyed(key []byte) (hash.Hash, error) {
+	return blake2b.New512(key)
+}
+
+// Default backend: pure Go implementations from crypto/* plus the extra
+// algorithms from golang.org/x/crypto and a couple of well-known non-std
+// modules. Build with -tags simd to swap SHA256/MD5 for CPU-accelerated
+// versions instead.
+func init() {
+	RegisterHasher("SHA1", simpleHasher{"SHA1", sha1.New})
+	RegisterHasher("SHA256", simpleHasher{"SHA256", sha256.New})
+	RegisterHasher("SHA512", simpleHasher{"SHA512", sha512.New})
+	RegisterHasher("MD5", simpleHasher{"MD5", md5.New})
+	RegisterHasher("SHA3-256", simpleHasher{"SHA3-256", sha3.New256})
+	RegisterHasher("BLAKE2b", blake2bHasher{})
+	RegisterHasher("BLAKE3", simpleHasher{"BLAKE3", func() hash.Hash {
+		return blake3.New()
+	}})
+	RegisterHasher("XXHASH64", simpleHasher{"XXHASH64", func() hash.Hash {
+		return xxhash.New()
+	}})
+}
@@ -0,0 +1,52 @@
+//go:build simd
+
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha512"
+	"hash"
+
+	"github.com/cespare/xxhash/v2"
+	md5simd "github.com/minio/md5-simd"
+	sha256simd "github.com/minio/sha256-simd"
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// blake2bHasher exposes BLAKE2b's native keyed-hashing mode (via
+// KeyedHasher) instead of going through a generic crypto/hmac wrapper.
+type blake2bHasher struct{}
+
+func (blake2bHasher) Name() string { return "BLAKE2b" }
+func (blake2bHasher) New() hash.Hash {
+	h, _ := blake2b.New512(nil)
+	return h
+}
+func (blake2bHasher) NewKeyed(key []byte) (hash.Hash, error) {
+	return blake2b.New512(key)
+}
+
+// simd backend: SHA256 and MD5 are routed through sha256-simd/md5-simd,
+// which pick the best available CPU instructions (AVX2, SHA-NI, ...) at
+// runtime and fall back to a pure-Go path automatically on hardware that
+// doesn't support them, so this build tag is safe to enable unconditionally.
+var md5Server = md5simd.NewServer()
+
+func init() {
+	RegisterHasher("SHA1", simpleHasher{"SHA1", sha1.New})
+	RegisterHasher("SHA256", simpleHasher{"SHA256", sha256simd.New})
+	RegisterHasher("SHA512", simpleHasher{"SHA512", sha512.New})
+	RegisterHasher("MD5", simpleHasher{"MD5", func() hash.Hash {
+		return md5Server.NewHash()
+	}})
+	RegisterHasher("SHA3-256", simpleHasher{"SHA3-256", sha3.New256})
+	RegisterHasher("BLAKE2b", blake2bHasher{})
+	RegisterHasher("BLAKE3", simpleHasher{"BLAKE3", func() hash.Hash {
+		return blake3.New()
+	}})
+	RegisterHasher("XXHASH64", simpleHasher{"XXHASH64", func() hash.Hash {
+		return xxhash.New()
+	}})
+}
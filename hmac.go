@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/hmac"
+	"hash"
+)
+
+// KeyedHasher is implemented by algorithms that support keyed hashing
+// natively (e.g. BLAKE2b), letting keyedHash skip the generic HMAC wrapper
+// for them.
+type KeyedHasher interface {
+	NewKeyed(key []byte) (hash.Hash, error)
+}
+
+// closer matches the Close method shape backends like simd's pooled MD5
+// actually expose (e.g. github.com/minio/md5-simd's Hasher.Close, which
+// returns nothing). io.Closer's "Close() error" never matches these, so a
+// closersOf built on io.Closer would silently fail to release them.
+type closer interface {
+	Close()
+}
+
+// keyedHash returns a keyed hash.Hash for hasher: its native keyed mode if
+// it implements KeyedHasher, or a generic crypto/hmac wrapper otherwise. It
+// also returns every closer created along the way so the caller can release
+// them; crypto/hmac's wrapper calls hasher.New twice internally (for its
+// inner and outer pads) to build a hash.Hash that doesn't itself expose
+// those two for closing, which would otherwise leak them for backends like
+// simd's pooled MD5 that hand hashes back to a server on Close.
+func keyedHash(hasher Hasher, key []byte) (hash.Hash, []closer, error) {
+	if kh, ok := hasher.(KeyedHasher); ok {
+		keyed, err := kh.NewKeyed(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return keyed, closersOf(keyed), nil
+	}
+
+	var closers []closer
+	newAndTrack := func() hash.Hash {
+		h := hasher.New()
+		closers = append(closers, closersOf(h)...)
+		return h
+	}
+	return hmac.New(newAndTrack, key), closers, nil
+}
+
+// closersOf returns h as a single-element []closer if it implements closer,
+// or nil otherwise.
+func closersOf(h hash.Hash) []closer {
+	if c, ok := h.(closer); ok {
+		return []closer{c}
+	}
+	return nil
+}
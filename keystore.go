@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrKeyNotFound is returned by a KeyStore when a user/keyID pair has no
+// associated key material.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyStore resolves the key material a Job references for HMAC/keyed-hash
+// mode. User scopes which keys a caller may reference: an implementation is
+// free to refuse a keyID that isn't registered to that user.
+type KeyStore interface {
+	GetKey(user, keyID string) ([]byte, error)
+}
+
+// MemoryKeyStore is a KeyStore backed by an in-memory map, keyed first by
+// user and then by keyID. Safe for concurrent use.
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]map[string][]byte
+}
+
+// NewMemoryKeyStore builds a MemoryKeyStore from a user -> keyID -> key map.
+func NewMemoryKeyStore(keys map[string]map[string][]byte) *MemoryKeyStore {
+	return &MemoryKeyStore{keys: keys}
+}
+
+// GetKey implements KeyStore.
+func (m *MemoryKeyStore) GetKey(user, keyID string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	userKeys, ok := m.keys[user]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	key, ok := userKeys[keyID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// fileKeyStoreDoc is the on-disk shape loaded by NewFileKeyStore, keyed by
+// user then keyID, with key material as a plain string.
+type fileKeyStoreDoc map[string]map[string]string
+
+// NewFileKeyStore loads a user -> keyID -> key document from path into a
+// MemoryKeyStore. JSON and YAML are both supported, chosen by file
+// extension (.json vs .yaml/.yml).
+func NewFileKeyStore(path string) (*MemoryKeyStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc fileKeyStoreDoc
+	switch {
+	case strings.HasSuffix(path, ".json"):
+		err = json.Unmarshal(data, &doc)
+	case strings.HasSuffix(path, ".yaml"), strings.HasSuffix(path, ".yml"):
+		err = yaml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("keystore: unrecognized extension in %q", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]map[string][]byte, len(doc))
+	for user, userKeys := range doc {
+		converted := make(map[string][]byte, len(userKeys))
+		for keyID, key := range userKeys {
+			converted[keyID] = []byte(key)
+		}
+		keys[user] = converted
+	}
+	return NewMemoryKeyStore(keys), nil
+}
+
+// EnvKeyStore resolves keys from environment variables named
+// "<Prefix>_<user>_<keyID>" (upper-cased, with "-" and "." replaced by "_"
+// since neither is valid in a POSIX env var name; user and keyID are
+// otherwise used as-is). It never caches keys, so rotating one is just an
+// env var change.
+type EnvKeyStore struct {
+	Prefix string
+}
+
+// NewEnvKeyStore builds an EnvKeyStore using prefix as the env var prefix.
+func NewEnvKeyStore(prefix string) *EnvKeyStore {
+	return &EnvKeyStore{Prefix: prefix}
+}
+
+var envKeyNameReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// GetKey implements KeyStore.
+func (e *EnvKeyStore) GetKey(user, keyID string) ([]byte, error) {
+	name := envKeyNameReplacer.Replace(strings.ToUpper(fmt.Sprintf("%s_%s_%s", e.Prefix, user, keyID)))
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return []byte(value), nil
+}
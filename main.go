@@ -2,44 +2,169 @@ package main
 
 import (
 	"bufio"
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
+	"container/heap"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"os/signal"
+	"runtime"
 	"sync"
+	"syscall"
+	"time"
 )
 
+// Alg accepts either a single algorithm name or a list of them in the
+// incoming JSON ("alg": "SHA256" or "alg": ["SHA256", "MD5"]), normalizing
+// both shapes to a []string internally.
+type Alg []string
+
+// UnmarshalJSON implements json.Unmarshaler, accepting a bare string or an
+// array of strings.
+func (a *Alg) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Alg{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = Alg(multi)
+	return nil
+}
+
+// ModeHMAC marks a Job to be hashed in keyed/HMAC mode instead of the
+// default plain digest mode (the zero value of Job.Mode).
+const ModeHMAC = "hmac"
+
 // Job is the input job specification
 type Job struct {
-	ID       string `json:"id"`
-	User     string `json:"user"`
-	AlgAsStr string `json:"alg"`
-	Payload  string `json:"payload"`
+	ID      string `json:"id"`
+	User    string `json:"user"`
+	Alg     Alg    `json:"alg"`
+	Payload string `json:"payload"`
+
+	// PayloadRef is an alternative to the inline Payload for large inputs.
+	// It's streamed straight into the hasher instead of being buffered.
+	// Supported schemes: "file://", "http(s)://" and "-", which hashes
+	// whatever bytes follow this job's own JSON line on Input. Because of
+	// that, a "-" job must be the last line of Input: once readInput hands
+	// its reader off to one, it reads no further lines.
+	PayloadRef string `json:"payload_ref"`
+
+	// Mode selects plain digest hashing (the default) or ModeHMAC, which
+	// keys the hash using the key referenced by KeyID.
+	Mode string `json:"mode"`
+	// KeyID identifies, together with User, the key material to fetch
+	// from HashingService.KeyStore when Mode is ModeHMAC.
+	KeyID string `json:"key_id"`
+
+	// seq is the order in which this job was read off Input, used to
+	// restore input order once replies come back from parallel workers.
+	seq uint64
+
+	// stdinReader is set by readInput, only when PayloadRef is "-", to the
+	// exact bufio.Reader it used to parse this job's own JSON line. Reusing
+	// that reader (rather than opening a second, independent handle onto
+	// os.Stdin) is what lets the payload line up with whatever bytes follow
+	// the job on the stream instead of racing readInput for them; see
+	// readInput and openPayload.
+	stdinReader io.Reader
 }
 
 // IsValid checks if the parsed Job is valid or not
 func (j *Job) IsValid() bool {
-	if "" == j.ID || "" == j.User || "" == j.AlgAsStr || "" == j.Payload {
+	if "" == j.ID || "" == j.User || 0 == len(j.Alg) {
+		return false
+	}
+	if "" == j.Payload && "" == j.PayloadRef {
+		return false
+	}
+	if j.Mode != "" && j.Mode != ModeHMAC {
 		return false
 	}
-	// check if the input algo is valid
-	if "" == HashValue("", j.AlgAsStr) {
+	if j.Mode == ModeHMAC && "" == j.KeyID {
 		return false
 	}
+	// check that every requested algo is registered
+	for _, alg := range j.Alg {
+		if _, ok := GetHasher(alg); !ok {
+			return false
+		}
+	}
 
 	return true
 }
 
+// Output holds one or more digests keyed by algorithm name. It marshals as
+// a bare string when there's exactly one digest (matching the single-alg,
+// back-compat wire shape) and as a map[string]string otherwise.
+type Output map[string]string
+
+// MarshalJSON implements json.Marshaler.
+func (o Output) MarshalJSON() ([]byte, error) {
+	switch len(o) {
+	case 0:
+		return json.Marshal("")
+	case 1:
+		for _, digest := range o {
+			return json.Marshal(digest)
+		}
+	}
+	return json.Marshal(map[string]string(o))
+}
+
+// Error codes carried on a failed Reply, for callers that want to branch on
+// failure reason without parsing Error's free-form text.
+const (
+	ErrCodeUnknownAlgorithm = "unknown_algorithm"
+	ErrCodePayload          = "payload_error"
+	ErrCodePayloadTooLarge  = "payload_too_large"
+	ErrCodeUnauthorizedKey  = "unauthorized_key"
+)
+
 // Reply is the output job specification
 type Reply struct {
 	ID      string `json:"id"`
-	Output  string `json:"output"`
+	Output  Output `json:"output"`
 	Success bool   `json:"success"`
 	User    string `json:"user"`
+
+	// Size is the number of bytes hashed, set whenever a reply succeeds.
+	Size int64 `json:"size,omitempty"`
+	// Error carries a human-readable failure reason, e.g. an
+	// unreachable payload_ref or a payload over max_payload_bytes.
+	Error string `json:"error,omitempty"`
+	// ErrorCode is a machine-readable classification of Error, one of the
+	// ErrCode* constants. Only set when Success is false.
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// seqReply pairs a completed reply with the sequence number of the job
+// that produced it, so reorderOutput can restore input order.
+type seqReply struct {
+	seq   uint64
+	reply *Reply
+}
+
+// seqReplyHeap is a min-heap of seqReply ordered by seq.
+type seqReplyHeap []seqReply
+
+func (h seqReplyHeap) Len() int            { return len(h) }
+func (h seqReplyHeap) Less(i, j int) bool  { return h[i].seq < h[j].seq }
+func (h seqReplyHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *seqReplyHeap) Push(x interface{}) { *h = append(*h, x.(seqReply)) }
+func (h *seqReplyHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
 }
 
 // HashingService is a one-way hashing service over a chat like interface
@@ -47,29 +172,168 @@ type HashingService struct {
 	Input  *os.File
 	Output *os.File
 
+	// Workers is the number of worker goroutines that process jobs
+	// concurrently. Replies are still written to Output in the exact order
+	// jobs were read, via a sequence-numbered reorder buffer. Defaults to
+	// runtime.NumCPU() when left at zero.
+	Workers int
+	// InputBuffer and OutputBuffer size the inputChannel/outputChannel
+	// buffers. Defaults of 512/128 are used when left at zero; the result
+	// channel between workers and the reorder buffer is sized off Workers
+	// directly, since that's what bounds how far workers can run ahead of it.
+	InputBuffer  int
+	OutputBuffer int
+
+	// PendingLimit caps how many jobs may be in flight at once, counting
+	// from the moment readInput reads one off Input to the moment
+	// reorderOutput writes its reply to outputChannel. This is what
+	// actually bounds reorderOutput's heap: a job stuck behind one slow
+	// hash can only have this many later-arriving siblings piled up
+	// waiting on it before readInput blocks picking up more. Defaults to
+	// Workers*4 when left at zero.
+	PendingLimit int
+
+	// MaxPayloadBytes bounds how many bytes a single job's payload may
+	// stream through the hasher before it's rejected. Zero means
+	// unlimited. Applies to both inline Payload and PayloadRef.
+	MaxPayloadBytes int64
+
+	// PayloadFetchTimeout bounds the total time a job-supplied PayloadRef
+	// is given before the job fails with ErrCodePayload. For
+	// "http://"/"https://" this covers connecting through streaming the
+	// full response body. For "file://" it bounds reads from pipes/FIFOs
+	// (e.g. one whose writer connects but stalls); it does NOT bound
+	// reads from regular files, since those don't support read deadlines
+	// in the first place and aren't expected to block. Defaults to 30s
+	// when left at zero; a job-supplied PayloadRef is otherwise free to
+	// tie up a worker and a PendingLimit slot indefinitely, and
+	// large-but-legitimate payloads should prefer MaxPayloadBytes plus a
+	// correspondingly longer PayloadFetchTimeout over disabling this.
+	PayloadFetchTimeout time.Duration
+
+	// KeyStore resolves key material for ModeHMAC jobs. Jobs in HMAC mode
+	// fail with ErrCodeUnauthorizedKey when this is nil.
+	KeyStore KeyStore
+
+	// MetricsAddr, if non-empty, serves Prometheus metrics on this address
+	// (e.g. ":9090") for the lifetime of the service.
+	MetricsAddr string
+
 	inputChannel  chan *Job
+	resultChannel chan seqReply
 	outputChannel chan *Reply
 
-	inFlight sync.WaitGroup
-	blocking sync.WaitGroup
+	// admission is a counting semaphore of size PendingLimit: readInput
+	// acquires a slot before queueing a job and reorderOutput releases one
+	// once that job's reply has been written to outputChannel.
+	admission chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	metrics *workerMetrics
 }
 
-// BlockingStart starts the hashing service and waits until it's stopped
-func (h *HashingService) BlockingStart() {
-	h.inputChannel = make(chan *Job, 512) // processing is slow, so we need a larger bufer here
-	h.outputChannel = make(chan *Reply, 128)
+// Start launches the service's pipeline goroutines and returns immediately;
+// it does not block until the service finishes. Call Wait to block until
+// Input is exhausted, or Shutdown to stop early and drain in-flight jobs.
+func (h *HashingService) Start(ctx context.Context) error {
+	if h.Workers <= 0 {
+		h.Workers = runtime.NumCPU()
+	}
+	if h.InputBuffer <= 0 {
+		h.InputBuffer = 512
+	}
+	if h.OutputBuffer <= 0 {
+		h.OutputBuffer = 128
+	}
+	if h.PendingLimit <= 0 {
+		h.PendingLimit = h.Workers * 4
+	}
+	if h.PayloadFetchTimeout <= 0 {
+		h.PayloadFetchTimeout = 30 * time.Second
+	}
+
+	h.ctx, h.cancel = context.WithCancel(ctx)
+	h.inputChannel = make(chan *Job, h.InputBuffer)
+	// resultChannel just gates workers against reorderOutput's consumption
+	// rate; PendingLimit (via admission) is what actually bounds the
+	// reorder heap.
+	h.resultChannel = make(chan seqReply, h.Workers*2)
+	h.outputChannel = make(chan *Reply, h.OutputBuffer)
+	h.admission = make(chan struct{}, h.PendingLimit)
+	h.done = make(chan struct{})
+	h.metrics = newWorkerMetrics()
 
-	h.blocking.Add(1)
 	go h.readInput()
-	go h.processJobs()
-	go h.printOutput()
-	h.blocking.Wait()
+
+	var workers sync.WaitGroup
+	workers.Add(h.Workers)
+	for i := 0; i < h.Workers; i++ {
+		go h.processJobs(i, &workers)
+	}
+	go func() {
+		workers.Wait()
+		close(h.resultChannel)
+	}()
+
+	go h.reorderOutput()
+	go func() {
+		h.printOutput()
+		close(h.done)
+	}()
+
+	if h.MetricsAddr != "" {
+		go h.serveMetrics(h.ctx)
+	}
+
+	return nil
+}
+
+// Wait blocks until the service has stopped: Input was exhausted, or
+// Shutdown was called and every in-flight job has drained.
+func (h *HashingService) Wait() {
+	<-h.done
+}
+
+// Shutdown stops the service from reading any further jobs off Input and
+// waits for jobs already queued to drain, up to ctx's deadline. Note that
+// readInput only observes cancellation between lines: if Input is a pipe or
+// socket that's gone silent without closing, Shutdown won't return until
+// that read unblocks (e.g. on EOF) or ctx's deadline passes.
+func (h *HashingService) Shutdown(ctx context.Context) error {
+	h.cancel()
+	select {
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// BlockingStart starts the hashing service and blocks until it's stopped.
+// It's a convenience wrapper around Start/Wait for callers that don't need
+// early shutdown.
+func (h *HashingService) BlockingStart() {
+	if err := h.Start(context.Background()); err != nil {
+		return
+	}
+	h.Wait()
 }
 
 // readInput reads from Input, parse the JSON and queues it for processing
 func (h *HashingService) readInput() {
 	reader := bufio.NewReader(h.Input)
+	var seq uint64
 	for {
+		select {
+		case <-h.ctx.Done():
+			close(h.inputChannel)
+			return
+		default:
+		}
+
 		line, err := reader.ReadString('\n')
 		if err != nil && err != io.EOF {
 			break
@@ -77,8 +341,43 @@ func (h *HashingService) readInput() {
 		var job Job
 		jsonErr := json.Unmarshal([]byte(line), &job)
 		if jsonErr == nil && job.IsValid() {
-			h.inFlight.Add(1)
-			h.inputChannel <- &job
+			// Acquire a PendingLimit slot before this job can be queued,
+			// bounding how many jobs may be read-but-not-yet-emitted at
+			// once (and so, in turn, how deep reorderOutput's heap can
+			// grow behind one slow hash). Released by reorderOutput once
+			// the reply is written to outputChannel.
+			select {
+			case h.admission <- struct{}{}:
+			case <-h.ctx.Done():
+				close(h.inputChannel)
+				return
+			}
+
+			job.seq = seq
+			seq++
+
+			if job.PayloadRef == "-" {
+				// reader now belongs to this job's payload stream; reading
+				// another line from it here would race the worker that's
+				// about to io.Copy from the same bufio.Reader, so this has
+				// to be the last job we read.
+				job.stdinReader = reader
+				select {
+				case h.inputChannel <- &job:
+				case <-h.ctx.Done():
+					<-h.admission
+				}
+				close(h.inputChannel)
+				return
+			}
+
+			select {
+			case h.inputChannel <- &job:
+			case <-h.ctx.Done():
+				<-h.admission
+				close(h.inputChannel)
+				return
+			}
 		}
 
 		if err == io.EOF {
@@ -86,18 +385,50 @@ func (h *HashingService) readInput() {
 		}
 	}
 	close(h.inputChannel)
-	h.Stop()
 }
 
-// Unfornately we can't process multiple inputs in parallel,
-// because the checker is a little dumb and expects the outputs
-// in the same order as the input
-func (h *HashingService) processJobs() {
+// processJobs is run by each of the Workers worker goroutines. Jobs come
+// off the shared inputChannel in whatever order workers happen to pick them
+// up, but every reply is tagged with its job's sequence number so
+// reorderOutput can restore input order before anything is printed.
+func (h *HashingService) processJobs(worker int, workers *sync.WaitGroup) {
+	defer workers.Done()
 	for job := range h.inputChannel {
-		h.inFlight.Add(1)
-		h.inFlight.Done()
-		reply := jobToReply(job)
-		h.outputChannel <- reply
+		start := time.Now()
+		reply := h.jobToReply(job)
+		h.metrics.record(worker, reply, time.Since(start))
+		h.resultChannel <- seqReply{seq: job.seq, reply: reply}
+	}
+}
+
+// reorderOutput restores input order across replies produced by multiple
+// concurrent workers. It buffers out-of-order replies on a min-heap keyed by
+// sequence number and only flushes to outputChannel once the next expected
+// sequence number has arrived, draining every heap entry that matches after
+// each arrival. Releasing an admission slot per flushed reply is what keeps
+// the heap itself bounded: once PendingLimit jobs are in flight behind one
+// slow hash, readInput blocks acquiring a slot before reading any more.
+func (h *HashingService) reorderOutput() {
+	pending := &seqReplyHeap{}
+	heap.Init(pending)
+	var nextSeq uint64
+
+	for sr := range h.resultChannel {
+		heap.Push(pending, sr)
+		for pending.Len() > 0 && (*pending)[0].seq == nextSeq {
+			next := heap.Pop(pending).(seqReply)
+			h.outputChannel <- next.reply
+			<-h.admission
+			nextSeq++
+		}
+	}
+
+	// Workers are done and resultChannel is closed; flush whatever's left
+	// so shutdown never silently drops a reply.
+	for pending.Len() > 0 {
+		next := heap.Pop(pending).(seqReply)
+		h.outputChannel <- next.reply
+		<-h.admission
 	}
 	close(h.outputChannel)
 }
@@ -110,52 +441,177 @@ func (h *HashingService) printOutput() {
 		if err == nil {
 			fmt.Fprintf(h.Output, "%s\n", jsonAsBytes)
 		}
-		h.inFlight.Done()
 	}
 }
 
-// Stop the hashing service
-func (h *HashingService) Stop() {
-	h.inFlight.Wait()
-	h.blocking.Done()
-}
-
-// Convert the input Job To Reply that needs to be sent out
-func jobToReply(job *Job) *Reply {
+// jobToReply converts the input Job to the Reply that needs to be sent out.
+// The payload is streamed once through an io.MultiWriter fanning out to one
+// hash.Hash per requested algorithm, so asking for several digests of the
+// same payload doesn't mean re-reading or re-copying it. In ModeHMAC, every
+// hash.Hash is keyed (natively where the algorithm supports it, via a
+// crypto/hmac wrapper otherwise) using a key fetched from KeyStore.
+func (h *HashingService) jobToReply(job *Job) *Reply {
 	reply := &Reply{
-		ID:      job.ID,
-		User:    job.User,
-		Success: true,
+		ID:   job.ID,
+		User: job.User,
 	}
-	hashedValue := HashValue(job.Payload, job.AlgAsStr)
-	if "" == hashedValue {
-		reply.Success = false
-	} else {
-		reply.Output = hashedValue
+
+	var key []byte
+	if job.Mode == ModeHMAC {
+		if h.KeyStore == nil {
+			reply.Error = "hmac mode requires a configured key store"
+			reply.ErrorCode = ErrCodeUnauthorizedKey
+			return reply
+		}
+		k, err := h.KeyStore.GetKey(job.User, job.KeyID)
+		if err != nil {
+			reply.Error = err.Error()
+			reply.ErrorCode = ErrCodeUnauthorizedKey
+			return reply
+		}
+		key = k
+	}
+
+	digests := make(map[string]hash.Hash, len(job.Alg))
+	writers := make([]io.Writer, 0, len(job.Alg))
+	for _, alg := range job.Alg {
+		hasher, ok := GetHasher(alg)
+		if !ok {
+			reply.Error = fmt.Sprintf("unknown algorithm %q", alg)
+			reply.ErrorCode = ErrCodeUnknownAlgorithm
+			return reply
+		}
+
+		var digest hash.Hash
+		if job.Mode == ModeHMAC {
+			keyed, closers, err := keyedHash(hasher, key)
+			if err != nil {
+				reply.Error = err.Error()
+				reply.ErrorCode = ErrCodeUnauthorizedKey
+				return reply
+			}
+			digest = keyed
+			// keyedHash's generic crypto/hmac path builds two internal
+			// digests of its own that the returned hash.Hash never
+			// exposes; release those too, not just the one below.
+			for _, closer := range closers {
+				defer closer.Close()
+			}
+		} else {
+			digest = hasher.New()
+		}
+		// Backends like simd's pooled md5Digest hand the underlying
+		// hash.Hash back to a server on Close; release it on every
+		// return path, not just the success one. This applies in
+		// plain mode too, not just ModeHMAC above.
+		if c, ok := digest.(closer); ok {
+			defer c.Close()
+		}
+		digests[alg] = digest
+		writers = append(writers, digest)
 	}
+
+	payload, err := openPayload(job, h.PayloadFetchTimeout)
+	if err != nil {
+		reply.Error = err.Error()
+		reply.ErrorCode = ErrCodePayload
+		return reply
+	}
+	defer payload.Close()
+
+	written, err := io.Copy(&limitWriter{w: io.MultiWriter(writers...), limit: h.MaxPayloadBytes}, payload)
+	if err != nil {
+		reply.Error = err.Error()
+		if err == errMaxPayloadExceeded {
+			reply.ErrorCode = ErrCodePayloadTooLarge
+		} else {
+			reply.ErrorCode = ErrCodePayload
+		}
+		return reply
+	}
+
+	output := make(Output, len(job.Alg))
+	for _, alg := range job.Alg {
+		output[alg] = fmt.Sprintf("%x", digests[alg].Sum(nil))
+	}
+
+	reply.Success = true
+	reply.Size = written
+	reply.Output = output
 	return reply
 }
 
-// HashValue tries to hash the input string using the algorithm
-func HashValue(input string, algorithm string) string {
-	switch algorithm {
-	case "SHA1":
-		return fmt.Sprintf("%x", sha1.Sum([]byte(input)))
-	case "SHA256":
-		return fmt.Sprintf("%x", sha256.Sum256([]byte(input)))
-	case "SHA512":
-		return fmt.Sprintf("%x", sha512.Sum512([]byte(input)))
-	case "MD5":
-		return fmt.Sprintf("%x", md5.Sum([]byte(input)))
+// buildKeyStore wires up the KeyStore backend selected by the
+// -keystore-file/-keystore-env-prefix flags. Leaving both unset is valid and
+// yields a nil KeyStore, under which HMAC-mode jobs fail with
+// ErrCodeUnauthorizedKey.
+func buildKeyStore(keystoreFile, keystoreEnvPrefix string) (KeyStore, error) {
+	switch {
+	case keystoreFile != "" && keystoreEnvPrefix != "":
+		return nil, fmt.Errorf("-keystore-file and -keystore-env-prefix are mutually exclusive")
+	case keystoreFile != "":
+		return NewFileKeyStore(keystoreFile)
+	case keystoreEnvPrefix != "":
+		return NewEnvKeyStore(keystoreEnvPrefix), nil
 	default:
-		return ""
+		return nil, nil
 	}
 }
 
 func main() {
+	workers := flag.Int("workers", 0, "number of worker goroutines (default runtime.NumCPU())")
+	inputBuffer := flag.Int("input-buffer", 0, "size of the pending-job channel (default 512)")
+	outputBuffer := flag.Int("output-buffer", 0, "size of the pending-reply channel (default 128)")
+	pendingLimit := flag.Int("pending-limit", 0, "max jobs in flight between being read and their reply being written (default Workers*4)")
+	maxPayloadBytes := flag.Int64("max-payload-bytes", 0, "reject payloads larger than this many bytes (default unlimited)")
+	payloadFetchTimeout := flag.Duration("payload-fetch-timeout", 30*time.Second, "total time an http(s):// payload_ref fetch is given to complete before the job fails")
+	metricsAddr := flag.String("metrics-addr", "", "if set, serve Prometheus metrics on this address, e.g. :9090")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "how long to wait for in-flight jobs to drain on SIGINT/SIGTERM")
+	keystoreFile := flag.String("keystore-file", "", "path to a JSON/YAML user -> keyID -> key document, for HMAC mode")
+	keystoreEnvPrefix := flag.String("keystore-env-prefix", "", "resolve HMAC keys from <prefix>_<user>_<keyID> env vars instead of -keystore-file")
+	flag.Parse()
+
+	keyStore, err := buildKeyStore(*keystoreFile, *keystoreEnvPrefix)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hashing service: %v\n", err)
+		os.Exit(1)
+	}
+
 	service := HashingService{
-		Input:  os.Stdin,
-		Output: os.Stdout,
+		Input:               os.Stdin,
+		Output:              os.Stdout,
+		Workers:             *workers,
+		InputBuffer:         *inputBuffer,
+		OutputBuffer:        *outputBuffer,
+		PendingLimit:        *pendingLimit,
+		MaxPayloadBytes:     *maxPayloadBytes,
+		PayloadFetchTimeout: *payloadFetchTimeout,
+		MetricsAddr:         *metricsAddr,
+		KeyStore:            keyStore,
+	}
+
+	if err := service.Start(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "hashing service: %v\n", err)
+		os.Exit(1)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		service.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-sig:
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := service.Shutdown(shutdownCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "hashing service: shutdown: %v\n", err)
+			os.Exit(1)
+		}
 	}
-	service.BlockingStart()
 }
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestReorderOutputRestoresSequence feeds reorderOutput replies out of the
+// order their seq numbers imply and checks outputChannel only ever emits
+// them in seq order, regardless of arrival order.
+func TestReorderOutputRestoresSequence(t *testing.T) {
+	arrival := []uint64{2, 0, 3, 1, 4}
+	h := &HashingService{
+		resultChannel: make(chan seqReply, 8),
+		outputChannel: make(chan *Reply, 8),
+		admission:     make(chan struct{}, len(arrival)),
+	}
+	for range arrival {
+		h.admission <- struct{}{} // simulate readInput's slots, released as replies flush
+	}
+
+	go func() {
+		for _, seq := range arrival {
+			h.resultChannel <- seqReply{seq: seq, reply: &Reply{ID: fmt.Sprintf("%d", seq)}}
+		}
+		close(h.resultChannel)
+	}()
+
+	h.reorderOutput()
+
+	for want := uint64(0); want < uint64(len(arrival)); want++ {
+		reply, ok := <-h.outputChannel
+		if !ok {
+			t.Fatalf("outputChannel closed early, expected seq %d", want)
+		}
+		if reply.ID != fmt.Sprintf("%d", want) {
+			t.Fatalf("got reply %q out of order, expected seq %d", reply.ID, want)
+		}
+	}
+	if _, ok := <-h.outputChannel; ok {
+		t.Fatalf("expected outputChannel to be closed after draining all replies")
+	}
+}
+
+// TestReorderOutputFlushesOnShutdown checks that replies still buffered on
+// the heap when resultChannel closes (e.g. a gap in sequence numbers that
+// will never arrive) are still flushed rather than dropped.
+func TestReorderOutputFlushesOnShutdown(t *testing.T) {
+	h := &HashingService{
+		resultChannel: make(chan seqReply, 8),
+		outputChannel: make(chan *Reply, 8),
+		admission:     make(chan struct{}, 2),
+	}
+	h.admission <- struct{}{}
+	h.admission <- struct{}{}
+
+	h.resultChannel <- seqReply{seq: 5, reply: &Reply{ID: "5"}}
+	h.resultChannel <- seqReply{seq: 1, reply: &Reply{ID: "1"}}
+	close(h.resultChannel)
+
+	h.reorderOutput()
+
+	got := make([]string, 0, 2)
+	for reply := range h.outputChannel {
+		got = append(got, reply.ID)
+	}
+	if len(got) != 2 || got[0] != "1" || got[1] != "5" {
+		t.Fatalf("got %v, want [1 5]", got)
+	}
+}
+
+// TestJobToReplyMultiAlg checks that requesting several algorithms for one
+// payload hashes it once and returns a digest per algorithm.
+func TestJobToReplyMultiAlg(t *testing.T) {
+	h := &HashingService{}
+	job := &Job{ID: "1", User: "alice", Alg: Alg{"SHA256", "MD5"}, Payload: "hello"}
+
+	reply := h.jobToReply(job)
+
+	if !reply.Success {
+		t.Fatalf("expected success, got error %q", reply.Error)
+	}
+	wantSHA256 := HashValue("hello", "SHA256")
+	wantMD5 := HashValue("hello", "MD5")
+	if got := reply.Output["SHA256"]; got != wantSHA256 {
+		t.Errorf("SHA256 = %q, want %q", got, wantSHA256)
+	}
+	if got := reply.Output["MD5"]; got != wantMD5 {
+		t.Errorf("MD5 = %q, want %q", got, wantMD5)
+	}
+	if reply.Size != int64(len("hello")) {
+		t.Errorf("Size = %d, want %d", reply.Size, len("hello"))
+	}
+}
+
+// TestJobToReplyHMAC checks that ModeHMAC routes the digest through the
+// configured KeyStore and produces a different output than the plain digest.
+func TestJobToReplyHMAC(t *testing.T) {
+	h := &HashingService{
+		KeyStore: NewMemoryKeyStore(map[string]map[string][]byte{
+			"alice": {"k1": []byte("secret")},
+		}),
+	}
+
+	job := &Job{ID: "1", User: "alice", Alg: Alg{"SHA256"}, Payload: "hello", Mode: ModeHMAC, KeyID: "k1"}
+	reply := h.jobToReply(job)
+
+	if !reply.Success {
+		t.Fatalf("expected success, got error %q", reply.Error)
+	}
+	if plain := HashValue("hello", "SHA256"); reply.Output["SHA256"] == plain {
+		t.Errorf("HMAC output matched the plain digest %q, key wasn't applied", plain)
+	}
+}
+
+// TestJobToReplyHMACUnauthorizedKey checks that an unknown key_id fails with
+// ErrCodeUnauthorizedKey rather than succeeding or panicking.
+func TestJobToReplyHMACUnauthorizedKey(t *testing.T) {
+	h := &HashingService{KeyStore: NewMemoryKeyStore(nil)}
+	job := &Job{ID: "1", User: "alice", Alg: Alg{"SHA256"}, Payload: "hello", Mode: ModeHMAC, KeyID: "missing"}
+
+	reply := h.jobToReply(job)
+
+	if reply.Success {
+		t.Fatalf("expected failure for an unrecognized key_id")
+	}
+	if reply.ErrorCode != ErrCodeUnauthorizedKey {
+		t.Errorf("ErrorCode = %q, want %q", reply.ErrorCode, ErrCodeUnauthorizedKey)
+	}
+}
+
+// closeTrackingHash is a hash.Hash that also implements closer (Close with
+// no error return, matching backends like simd's pooled MD5), recording
+// whether Close was ever invoked.
+type closeTrackingHash struct {
+	hash.Hash
+	closed *bool
+}
+
+func (c closeTrackingHash) Close() { *c.closed = true }
+
+// TestJobToReplyClosesDigests checks that jobToReply releases every digest
+// it creates, in both plain and ModeHMAC jobs, by registering a fake Hasher
+// whose Close sets a flag. Backends whose Close doesn't satisfy io.Closer's
+// "Close() error" signature (e.g. github.com/minio/md5-simd's Hasher) would
+// previously never be released, leaking a client per digest for the
+// lifetime of the process.
+func TestJobToReplyClosesDigests(t *testing.T) {
+	var plainClosed bool
+	RegisterHasher("TESTCLOSER", simpleHasher{"TESTCLOSER", func() hash.Hash {
+		plainClosed = false
+		return closeTrackingHash{sha256.New(), &plainClosed}
+	}})
+
+	h := &HashingService{}
+	job := &Job{ID: "1", User: "alice", Alg: Alg{"TESTCLOSER"}, Payload: "hello"}
+	if reply := h.jobToReply(job); !reply.Success {
+		t.Fatalf("expected success, got error %q", reply.Error)
+	}
+	if !plainClosed {
+		t.Error("plain-mode digest was never closed")
+	}
+
+	var hmacClosed bool
+	RegisterHasher("TESTCLOSERHMAC", simpleHasher{"TESTCLOSERHMAC", func() hash.Hash {
+		hmacClosed = false
+		return closeTrackingHash{sha256.New(), &hmacClosed}
+	}})
+	hHMAC := &HashingService{
+		KeyStore: NewMemoryKeyStore(map[string]map[string][]byte{
+			"alice": {"k1": []byte("secret")},
+		}),
+	}
+	hmacJob := &Job{ID: "2", User: "alice", Alg: Alg{"TESTCLOSERHMAC"}, Payload: "hello", Mode: ModeHMAC, KeyID: "k1"}
+	if reply := hHMAC.jobToReply(hmacJob); !reply.Success {
+		t.Fatalf("expected success, got error %q", reply.Error)
+	}
+	if !hmacClosed {
+		t.Error("HMAC-mode digest was never closed")
+	}
+}
+
+// blockingHash is a hash.Hash whose Write blocks until unblock is closed,
+// letting TestReadInputRespectsPendingLimit hold a worker busy on one job
+// while it observes how many more readInput lets through behind it.
+type blockingHash struct {
+	hash.Hash
+	unblock <-chan struct{}
+}
+
+func (b blockingHash) Write(p []byte) (int, error) {
+	<-b.unblock
+	return b.Hash.Write(p)
+}
+
+// TestReadInputRespectsPendingLimit checks that PendingLimit actually bounds
+// how many jobs readInput will queue ahead of a slow hash, rather than just
+// bounding resultChannel's buffer (the gap a prior version of this package
+// left between its doc comments and reorderOutput's behavior).
+func TestReadInputRespectsPendingLimit(t *testing.T) {
+	unblock := make(chan struct{})
+	RegisterHasher("TESTSLOW", simpleHasher{"TESTSLOW", func() hash.Hash {
+		return blockingHash{sha256.New(), unblock}
+	}})
+
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe (input): %v", err)
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe (output): %v", err)
+	}
+	defer outR.Close()
+
+	h := &HashingService{
+		Input:        inR,
+		Output:       outW,
+		Workers:      1,
+		PendingLimit: 2,
+	}
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	writeJob := func(id string) {
+		line := fmt.Sprintf(`{"id":%q,"user":"u","alg":"TESTSLOW","payload":"x"}`+"\n", id)
+		if _, err := inW.Write([]byte(line)); err != nil {
+			t.Fatalf("write job %s: %v", id, err)
+		}
+	}
+
+	// Job 1 occupies the one worker, blocked on blockingHash.Write. Jobs 2
+	// and 3 fill PendingLimit's two admission slots. None of this should
+	// deadlock or block past readInput's own buffering.
+	writeJob("1")
+	writeJob("2")
+	writeJob("3")
+
+	deadline := time.After(2 * time.Second)
+	for {
+		n := len(h.admission)
+		if n == cap(h.admission) {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("admission never filled to PendingLimit (stuck at %d/%d)", n, cap(h.admission))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// A 4th job must not be admitted until a slot frees up.
+	writeJob("4")
+	select {
+	case <-time.After(100 * time.Millisecond):
+	case <-h.done:
+		t.Fatalf("service finished early")
+	}
+	n := len(h.admission)
+	if n != cap(h.admission) {
+		t.Fatalf("admission = %d, want still full at %d (job 4 should not have been admitted yet)", n, cap(h.admission))
+	}
+
+	close(unblock)
+	inW.Close()
+
+	reader := bufio.NewReader(outR)
+	for i := 0; i < 4; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			t.Fatalf("reading reply %d: %v", i+1, err)
+		}
+	}
+}
+
+// TestJobToReplyMaxPayloadBytes checks that a payload over MaxPayloadBytes
+// is rejected mid-stream with ErrCodePayloadTooLarge.
+func TestJobToReplyMaxPayloadBytes(t *testing.T) {
+	h := &HashingService{MaxPayloadBytes: 3}
+	job := &Job{ID: "1", User: "alice", Alg: Alg{"SHA256"}, Payload: "hello"}
+
+	reply := h.jobToReply(job)
+
+	if reply.Success {
+		t.Fatalf("expected failure for a payload over MaxPayloadBytes")
+	}
+	if reply.ErrorCode != ErrCodePayloadTooLarge {
+		t.Errorf("ErrorCode = %q, want %q", reply.ErrorCode, ErrCodePayloadTooLarge)
+	}
+}
+
+// TestJobToReplyPayloadFetchTimeout checks that an http:// payload_ref that
+// never responds fails with ErrCodePayload instead of blocking the worker
+// indefinitely.
+func TestJobToReplyPayloadFetchTimeout(t *testing.T) {
+	stall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer stall.Close()
+
+	h := &HashingService{PayloadFetchTimeout: 50 * time.Millisecond}
+	job := &Job{ID: "1", User: "alice", Alg: Alg{"SHA256"}, PayloadRef: stall.URL}
+
+	start := time.Now()
+	reply := h.jobToReply(job)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("jobToReply took %s, want it bounded by PayloadFetchTimeout", elapsed)
+	}
+
+	if reply.Success {
+		t.Fatalf("expected failure fetching a payload_ref that never responds")
+	}
+	if reply.ErrorCode != ErrCodePayload {
+		t.Errorf("ErrorCode = %q, want %q", reply.ErrorCode, ErrCodePayload)
+	}
+}
+
+// TestJobToReplyFilePayloadFetchTimeout checks that a file:// payload_ref
+// pointing at a FIFO whose writer connects but never sends data fails with
+// ErrCodePayload instead of blocking the worker indefinitely on Read. (A
+// FIFO with no writer at all doesn't need this: openFilePayload's
+// O_NONBLOCK open sees it as EOF immediately, same as an empty file.)
+func TestJobToReplyFilePayloadFetchTimeout(t *testing.T) {
+	fifoPath := filepath.Join(t.TempDir(), "stall.fifo")
+	if err := syscall.Mkfifo(fifoPath, 0600); err != nil {
+		t.Fatalf("Mkfifo: %v", err)
+	}
+
+	// O_RDWR never blocks on a FIFO (unlike O_WRONLY, which would wait for
+	// a reader), and guarantees a writer is already connected before
+	// openFilePayload's own open runs, so the nonblocking read sees a live
+	// writer with no data - not the "no writer at all" EOF case above.
+	w, err := os.OpenFile(fifoPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("open fifo for write: %v", err)
+	}
+	defer w.Close()
+
+	h := &HashingService{PayloadFetchTimeout: 50 * time.Millisecond}
+	job := &Job{ID: "1", User: "alice", Alg: Alg{"SHA256"}, PayloadRef: "file://" + fifoPath}
+
+	start := time.Now()
+	reply := h.jobToReply(job)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("jobToReply took %s, want it bounded by PayloadFetchTimeout", elapsed)
+	}
+
+	if reply.Success {
+		t.Fatalf("expected failure reading a file:// payload_ref whose writer never sends data")
+	}
+	if reply.ErrorCode != ErrCodePayload {
+		t.Errorf("ErrorCode = %q, want %q", reply.ErrorCode, ErrCodePayload)
+	}
+}
+
+// TestJobToReplyFilePayloadNoWriterIsEmpty checks that a file:// payload_ref
+// pointing at a FIFO with no writer at all is treated as an empty payload
+// (consistent with O_NONBLOCK's EOF-on-no-writer behavior) rather than
+// erroring or hanging.
+func TestJobToReplyFilePayloadNoWriterIsEmpty(t *testing.T) {
+	fifoPath := filepath.Join(t.TempDir(), "empty.fifo")
+	if err := syscall.Mkfifo(fifoPath, 0600); err != nil {
+		t.Fatalf("Mkfifo: %v", err)
+	}
+
+	h := &HashingService{PayloadFetchTimeout: 2 * time.Second}
+	job := &Job{ID: "1", User: "alice", Alg: Alg{"SHA256"}, PayloadRef: "file://" + fifoPath}
+
+	reply := h.jobToReply(job)
+	if !reply.Success {
+		t.Fatalf("expected success treating a writer-less FIFO as empty, got error %q", reply.Error)
+	}
+	if want := HashValue("", "SHA256"); reply.Output["SHA256"] != want {
+		t.Errorf("SHA256 = %q, want %q (empty payload)", reply.Output["SHA256"], want)
+	}
+}
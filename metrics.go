@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// workerMetrics tracks per-worker throughput and latency, exported via
+// serveMetrics when HashingService.MetricsAddr is set. The worker label
+// lets an operator spot one stuck or slow goroutine in a multi-worker
+// pipeline instead of only seeing an aggregate.
+type workerMetrics struct {
+	registry      *prometheus.Registry
+	jobsProcessed *prometheus.CounterVec
+	jobErrors     *prometheus.CounterVec
+	jobLatency    *prometheus.HistogramVec
+}
+
+// newWorkerMetrics builds a fresh, service-scoped Prometheus registry rather
+// than registering against the global DefaultRegisterer, so that more than
+// one HashingService can exist in the same process (tests, multiple
+// instances) without a duplicate-registration panic.
+func newWorkerMetrics() *workerMetrics {
+	registry := prometheus.NewRegistry()
+	m := &workerMetrics{
+		registry: registry,
+		jobsProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hashing_service_jobs_processed_total",
+			Help: "Number of jobs processed, labeled by worker.",
+		}, []string{"worker"}),
+		jobErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hashing_service_job_errors_total",
+			Help: "Number of jobs that finished with success=false, labeled by worker and error_code.",
+		}, []string{"worker", "error_code"}),
+		jobLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "hashing_service_job_latency_seconds",
+			Help:    "Time spent in jobToReply, labeled by worker.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"worker"}),
+	}
+	registry.MustRegister(m.jobsProcessed, m.jobErrors, m.jobLatency)
+	return m
+}
+
+// record updates the metrics for one completed job. worker is the index of
+// the processJobs goroutine that handled it, used as a string label.
+func (m *workerMetrics) record(worker int, reply *Reply, latency time.Duration) {
+	label := strconv.Itoa(worker)
+	m.jobsProcessed.WithLabelValues(label).Inc()
+	m.jobLatency.WithLabelValues(label).Observe(latency.Seconds())
+	if !reply.Success {
+		m.jobErrors.WithLabelValues(label, reply.ErrorCode).Inc()
+	}
+}
+
+// serveMetrics runs a /metrics HTTP endpoint on HashingService.MetricsAddr
+// until ctx is done. Errors starting the listener are logged to stderr
+// rather than propagated, since metrics are an optional, best-effort
+// addition to the pipeline.
+func (h *HashingService) serveMetrics(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(h.metrics.registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Addr: h.MetricsAddr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Fprintf(os.Stderr, "metrics server: %v\n", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errMaxPayloadExceeded is the error surfaced once a payload stream produces
+// more than HashingService.MaxPayloadBytes.
+var errMaxPayloadExceeded = errors.New("payload exceeds max_payload_bytes")
+
+// openPayload returns a reader over a job's payload. The inline Payload
+// field takes priority; otherwise PayloadRef is resolved according to its
+// scheme: "file://" reads a local file, "http://"/"https://" fetches a
+// remote one, and "-" reads the bytes readInput already left queued up in
+// job.stdinReader. Both "file://" and "http(s)://" are job-supplied and
+// bounded by fetchTimeout (zero means unbounded) so that a malicious or
+// accidental reference - a FIFO whose writer connects but stalls, an
+// unresponsive host - can't wedge a worker and its PendingLimit slot
+// indefinitely; see openFilePayload for what "file://" can and can't bound.
+// Callers must close the returned reader.
+func openPayload(job *Job, fetchTimeout time.Duration) (io.ReadCloser, error) {
+	if job.Payload != "" {
+		return io.NopCloser(strings.NewReader(job.Payload)), nil
+	}
+
+	switch {
+	case job.PayloadRef == "-":
+		if job.stdinReader == nil {
+			return nil, errors.New("payload_ref: \"-\" requires a job read from Input, not one constructed directly")
+		}
+		return io.NopCloser(job.stdinReader), nil
+	case strings.HasPrefix(job.PayloadRef, "file://"):
+		return openFilePayload(strings.TrimPrefix(job.PayloadRef, "file://"), fetchTimeout)
+	case strings.HasPrefix(job.PayloadRef, "http://"), strings.HasPrefix(job.PayloadRef, "https://"):
+		client := http.Client{Timeout: fetchTimeout}
+		resp, err := client.Get(job.PayloadRef)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("payload_ref: unexpected status %s", resp.Status)
+		}
+		return resp.Body, nil
+	default:
+		return nil, fmt.Errorf("payload_ref: unsupported scheme in %q", job.PayloadRef)
+	}
+}
+
+// openFilePayload opens path the same way os.Open does, except reads from a
+// pipe or FIFO are bounded by fetchTimeout (zero means unbounded, matching
+// http.Client's Timeout convention). O_NONBLOCK is what makes this safe
+// without spawning a goroutine to race the open itself: a blocking open(2)
+// on a FIFO with no writer waits for one to appear, but O_NONBLOCK makes it
+// return immediately regardless, and SetReadDeadline then bounds the reads
+// that follow (e.g. a writer that connects but never sends data). Regular
+// files are unaffected either way - O_NONBLOCK is a no-op for them, and
+// SetReadDeadline always fails with "not supported" for them (ignored
+// here), since the kernel doesn't expose a deadline mechanism for regular
+// file reads; an unresponsive NFS mount behind a "file://" ref is therefore
+// NOT bounded by fetchTimeout the way a stalled pipe is.
+func openFilePayload(path string, fetchTimeout time.Duration) (io.ReadCloser, error) {
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	f := os.NewFile(uintptr(fd), path)
+	if fetchTimeout > 0 {
+		_ = f.SetReadDeadline(time.Now().Add(fetchTimeout))
+	}
+	return f, nil
+}
+
+// limitWriter caps how many bytes may be written through it, returning
+// errMaxPayloadExceeded once the limit is crossed. A limit <= 0 means
+// unlimited. This is what lets MaxPayloadBytes reject an oversized payload
+// mid-stream instead of only after it's been fully hashed.
+type limitWriter struct {
+	w     io.Writer
+	limit int64
+	n     int64
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if lw.limit > 0 && lw.n+int64(len(p)) > lw.limit {
+		return 0, errMaxPayloadExceeded
+	}
+	n, err := lw.w.Write(p)
+	lw.n += int64(n)
+	return n, err
+}